@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// ACL resolves a user's Role from configured owner/admin lists, matched
+// against either their NickServ account (preferred) or their hostmask, and
+// tracks a runtime-managed ignore list.
+type ACL struct {
+	mu      sync.Mutex
+	owners  map[string]bool // account name or hostmask, lowercased
+	admins  map[string]bool
+	ignored map[string]bool // nick, lowercased
+}
+
+// NewACL builds an ACL from the given owner/admin identifiers. Each
+// identifier is either a NickServ account name or a full hostmask
+// (nick!user@host, with '*' glob support via strings.Contains-style prefix
+// matching on the host part).
+func NewACL(owners, admins []string) *ACL {
+	acl := &ACL{
+		owners:  make(map[string]bool),
+		admins:  make(map[string]bool),
+		ignored: make(map[string]bool),
+	}
+	for _, id := range owners {
+		acl.owners[strings.ToLower(id)] = true
+	}
+	for _, id := range admins {
+		acl.admins[strings.ToLower(id)] = true
+	}
+	return acl
+}
+
+// Resolve returns the Role for a user identified by their NickServ account
+// (empty if not logged in) and their hostmask (nick!user@host).
+func (a *ACL) Resolve(account, hostmask string) Role {
+	account = strings.ToLower(account)
+	hostmask = strings.ToLower(hostmask)
+
+	if a.matches(a.owners, account, hostmask) {
+		return RoleOwner
+	}
+	if a.matches(a.admins, account, hostmask) {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+func (a *ACL) matches(set map[string]bool, account, hostmask string) bool {
+	if account != "" && set[account] {
+		return true
+	}
+	for pattern := range set {
+		if hostmaskMatches(pattern, hostmask) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostmaskMatches does simple '*'-glob matching of an IRC hostmask pattern,
+// e.g. "*!*@example.com" or "bob!*@*.isp.net".
+func hostmaskMatches(pattern, hostmask string) bool {
+	if hostmask == "" {
+		return false
+	}
+
+	parts := strings.Split(pattern, "*")
+	pos := 0
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		idx := strings.Index(hostmask[pos:], part)
+		if idx == -1 {
+			return false
+		}
+		if i == 0 && idx != 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	if last := parts[len(parts)-1]; last != "" && !strings.HasSuffix(hostmask, last) {
+		return false
+	}
+	return true
+}
+
+// Ignore adds nick to the runtime ignore list so command dispatch and the AI
+// fallback both skip messages from them.
+func (a *ACL) Ignore(nick string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ignored[strings.ToLower(nick)] = true
+}
+
+// Unignore removes nick from the ignore list.
+func (a *ACL) Unignore(nick string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.ignored, strings.ToLower(nick))
+}
+
+// IsIgnored reports whether nick is currently on the ignore list.
+func (a *ACL) IsIgnored(nick string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ignored[strings.ToLower(nick)]
+}
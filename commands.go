@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	irc "github.com/thoj/go-ircevent"
+)
+
+// Role is a privilege level resolved for the user issuing a command.
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleAdmin
+	RoleOwner
+)
+
+// CommandContext carries everything a Command or Plugin needs to act on a
+// single invocation: the bot it arrived on, the raw IRC event, and the
+// parsed command line.
+type CommandContext struct {
+	Bot      *IRCBot
+	Event    *irc.Event
+	Identity string // services account, or nick if not logged in
+	Nick     string
+	Target   string // where to send replies: the channel, or the nick for a PM
+	Role     Role
+	Args     []string // the command line split on whitespace, Args[0] excluded
+}
+
+// Reply sends msg back to the context's target.
+func (c *CommandContext) Reply(msg string) {
+	c.Bot.sendResponse(c.Target, msg)
+}
+
+// Command is a single prefix command, e.g. "!help".
+type Command struct {
+	Name        string
+	Description string
+	MinRole     Role
+	Handler     func(c *CommandContext) error
+}
+
+// Plugin lets external packages register additional commands and PRIVMSG
+// middleware without modifying the dispatcher itself.
+type Plugin interface {
+	// Register adds this plugin's commands and middleware to d.
+	Register(d *CommandDispatcher)
+}
+
+// Middleware runs for every PRIVMSG before command dispatch or the AI
+// fallback. Returning false stops further processing of the message.
+type Middleware func(c *CommandContext) bool
+
+// CommandDispatcher routes prefixed chat lines to registered Commands and
+// runs PRIVMSG middleware ahead of them. It also owns the ACL lists used to
+// resolve a user's Role.
+type CommandDispatcher struct {
+	prefix     string
+	commands   map[string]*Command
+	middleware []Middleware
+	acl        *ACL
+}
+
+// NewCommandDispatcher creates a dispatcher for the given command prefix
+// (e.g. "!") and ACL, and registers the bot's built-in commands.
+func NewCommandDispatcher(prefix string, acl *ACL) *CommandDispatcher {
+	d := &CommandDispatcher{
+		prefix:   prefix,
+		commands: make(map[string]*Command),
+		acl:      acl,
+	}
+	registerBuiltinCommands(d)
+	return d
+}
+
+// Register adds a command, overwriting any existing command of the same name.
+func (d *CommandDispatcher) Register(cmd *Command) {
+	d.commands[cmd.Name] = cmd
+}
+
+// Use appends a middleware to the chain, run in registration order.
+func (d *CommandDispatcher) Use(mw Middleware) {
+	d.middleware = append(d.middleware, mw)
+}
+
+// LoadPlugin lets a Plugin register its own commands and middleware.
+func (d *CommandDispatcher) LoadPlugin(p Plugin) {
+	p.Register(d)
+}
+
+// Dispatch runs middleware, then either executes a matching command or
+// reports that the message wasn't a command. handled is false when the
+// message isn't a command line at all, meaning the caller should fall back
+// to the normal AI response path.
+func (d *CommandDispatcher) Dispatch(c *CommandContext, line string) (handled bool) {
+	for _, mw := range d.middleware {
+		if !mw(c) {
+			return true
+		}
+	}
+
+	if !strings.HasPrefix(line, d.prefix) {
+		return false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, d.prefix))
+	if len(fields) == 0 {
+		return false
+	}
+
+	name := strings.ToLower(fields[0])
+	cmd, ok := d.commands[name]
+	if !ok {
+		return false
+	}
+
+	c.Args = fields[1:]
+
+	if c.Role < cmd.MinRole {
+		c.Reply(fmt.Sprintf("Sorry, %s requires a higher privilege level.", d.prefix+name))
+		return true
+	}
+
+	if err := cmd.Handler(c); err != nil {
+		log.Printf("[%s] Command %s failed: %v", c.Bot.config.Network, name, err)
+		c.Reply(fmt.Sprintf("Error running %s: %v", d.prefix+name, err))
+	}
+
+	return true
+}
+
+func registerBuiltinCommands(d *CommandDispatcher) {
+	d.Register(&Command{
+		Name:        "help",
+		Description: "List available commands",
+		MinRole:     RoleUser,
+		Handler: func(c *CommandContext) error {
+			names := make([]string, 0, len(d.commands))
+			for name := range d.commands {
+				names = append(names, d.prefix+name)
+			}
+			c.Reply("Available commands: " + strings.Join(names, ", "))
+			return nil
+		},
+	})
+
+	d.Register(&Command{
+		Name:        "persona",
+		Description: "persona get|set <text>|reset",
+		MinRole:     RoleAdmin,
+		Handler: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return fmt.Errorf("usage: persona get|set <text>|reset")
+			}
+
+			switch c.Args[0] {
+			case "get":
+				c.Reply(c.Bot.config.Persona)
+			case "set":
+				if len(c.Args) < 2 {
+					return fmt.Errorf("usage: persona set <text>")
+				}
+				c.Bot.config.Persona = strings.Join(c.Args[1:], " ")
+				c.Bot.contexts.ResetPersona(c.Bot.config.Network, c.Target, c.Bot.config.Persona)
+				c.Reply("Persona updated.")
+			case "reset":
+				c.Bot.contexts.Clear(c.Bot.config.Network, c.Target)
+				c.Reply("Persona and context reset to defaults.")
+			default:
+				return fmt.Errorf("usage: persona get|set <text>|reset")
+			}
+			return nil
+		},
+	})
+
+	d.Register(&Command{
+		Name:        "context",
+		Description: "context clear",
+		MinRole:     RoleUser,
+		Handler: func(c *CommandContext) error {
+			if len(c.Args) != 1 || c.Args[0] != "clear" {
+				return fmt.Errorf("usage: context clear")
+			}
+			c.Bot.contexts.Clear(c.Bot.config.Network, c.Target)
+			c.Reply("Conversation context cleared.")
+			return nil
+		},
+	})
+
+	d.Register(&Command{
+		Name:        "ignore",
+		Description: "ignore <nick>",
+		MinRole:     RoleAdmin,
+		Handler: func(c *CommandContext) error {
+			if len(c.Args) != 1 {
+				return fmt.Errorf("usage: ignore <nick>")
+			}
+			c.Bot.acl.Ignore(c.Args[0])
+			c.Reply(fmt.Sprintf("Ignoring %s.", c.Args[0]))
+			return nil
+		},
+	})
+
+	d.Register(&Command{
+		Name:        "model",
+		Description: "model <name>",
+		MinRole:     RoleAdmin,
+		Handler: func(c *CommandContext) error {
+			if len(c.Args) != 1 {
+				return fmt.Errorf("usage: model <name>")
+			}
+
+			setter, ok := c.Bot.llm.(ModelSetter)
+			if !ok {
+				return fmt.Errorf("the configured LLM backend doesn't support changing models at runtime")
+			}
+
+			setter.SetModel(c.Args[0])
+			c.Bot.config.LLM.Model = c.Args[0]
+			c.Reply(fmt.Sprintf("Model set to %s.", c.Args[0]))
+			return nil
+		},
+	})
+
+	d.Register(&Command{
+		Name:        "debug",
+		Description: "debug <level>",
+		MinRole:     RoleOwner,
+		Handler: func(c *CommandContext) error {
+			if len(c.Args) != 1 {
+				return fmt.Errorf("usage: debug <level>")
+			}
+			c.Bot.connection.Debug = c.Args[0] != "0"
+			c.Reply(fmt.Sprintf("Debug logging set to %s.", c.Args[0]))
+			return nil
+		},
+	})
+}
@@ -0,0 +1,14 @@
+package main
+
+// ctcpDelim wraps a CTCP command/reply payload inside a PRIVMSG or NOTICE.
+const ctcpDelim = "\x01"
+
+// defaultActionMarker is the Config.ActionMarker fallback: an LLM reply
+// beginning with this (after trimming whitespace) is sent as a CTCP ACTION
+// instead of a plain PRIVMSG.
+const defaultActionMarker = "*action*:"
+
+// wrapCTCP wraps body as a CTCP payload, e.g. for an ACTION or a query reply.
+func wrapCTCP(body string) string {
+	return ctcpDelim + body + ctcpDelim
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EmbeddingsClient calls an OpenAI-compatible /v1/embeddings endpoint. It
+// backs MemoryStore's vector-recall step: before each reply, the bot embeds
+// the incoming message and fetches the most semantically relevant past
+// turns for the channel, giving it long-term memory beyond its rolling
+// window of recent turns.
+type EmbeddingsClient struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewEmbeddingsClient builds a client against baseURL (defaulting to
+// OpenAI's API) using model for embedding requests.
+func NewEmbeddingsClient(baseURL, apiKey, model string) *EmbeddingsClient {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+	return &EmbeddingsClient{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+type embeddingsRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed returns the embedding vector for text.
+func (c *EmbeddingsClient) Embed(ctx context.Context, text string) ([]float64, error) {
+	payload, err := json.Marshal(embeddingsRequest{Model: c.model, Input: text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embeddings request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build embeddings request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embeddings endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := json.Marshal(map[string]int{"status": resp.StatusCode})
+		return nil, fmt.Errorf("embeddings endpoint returned non-200: %s", body)
+	}
+
+	var parsed embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %v", err)
+	}
+	if len(parsed.Data) == 0 {
+		return nil, fmt.Errorf("embeddings endpoint returned no data")
+	}
+
+	return parsed.Data[0].Embedding, nil
+}
+
+// cosineSimilarity returns the cosine similarity of a and b, or 0 if either
+// is empty or they differ in length.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
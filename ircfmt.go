@@ -0,0 +1,41 @@
+package main
+
+import "regexp"
+
+// IRC formatting control codes (mIRC/IRCv3 conventions).
+const (
+	ircBold      = "\x02"
+	ircItalic    = "\x1D"
+	ircMonospace = "\x11"
+)
+
+var (
+	markdownBold      = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownCode      = regexp.MustCompile("`([^`]+)`")
+	markdownItalicAst = regexp.MustCompile(`\*([^*]+)\*`)
+	markdownItalicUnd = regexp.MustCompile(`_([^_]+)_`)
+
+	ircColorCode      = regexp.MustCompile(`\x03(\d{1,2}(,\d{1,2})?)?`)
+	ircFormattingCode = regexp.MustCompile(`[\x02\x1D\x1F\x11\x16\x0F]`)
+)
+
+// markdownToIRC converts the small subset of Markdown emphasis LLMs tend to
+// produce (**bold**, *italic*/_italic_, `code`) into IRC formatting control
+// codes, so replies render with emphasis on clients that support it instead
+// of showing the literal asterisks/backticks.
+func markdownToIRC(s string) string {
+	s = markdownBold.ReplaceAllString(s, ircBold+"$1"+ircBold)
+	s = markdownCode.ReplaceAllString(s, ircMonospace+"$1"+ircMonospace)
+	s = markdownItalicAst.ReplaceAllString(s, ircItalic+"$1"+ircItalic)
+	s = markdownItalicUnd.ReplaceAllString(s, ircItalic+"$1"+ircItalic)
+	return s
+}
+
+// stripFormatting removes mIRC/IRC formatting control codes, including color
+// codes with their optional foreground/background digits, from s. Applied to
+// inbound messages so the LLM sees plain text instead of control bytes.
+func stripFormatting(s string) string {
+	s = ircColorCode.ReplaceAllString(s, "")
+	s = ircFormattingCode.ReplaceAllString(s, "")
+	return s
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	irc "github.com/thoj/go-ircevent"
+)
+
+// ircv3RequestedCaps are the IRCv3 capabilities every bot connection asks
+// the server for, in addition to "sasl" which go-ircevent requests
+// automatically when UseSASL is set. server-time lets us timestamp context
+// messages from the server's clock, message-tags/account-tag/echo-message
+// give us richer per-message metadata, and batch/multi-prefix keep replay
+// and NAMES output sane on busy networks.
+var ircv3RequestedCaps = []string{
+	"server-time",
+	"message-tags",
+	"account-tag",
+	"echo-message",
+	"batch",
+	"multi-prefix",
+}
+
+// accountOrNick returns the services account for e, as reported by the
+// account-tag capability, falling back to the nick when the account-tag
+// isn't present (not negotiated, or the user isn't logged in). Using the
+// account keeps a user's conversation history intact across nick changes.
+func accountOrNick(e *irc.Event) string {
+	if e.Tags != nil {
+		if account, ok := e.Tags["account"]; ok && account != "" && account != "*" {
+			return account
+		}
+	}
+	return e.Nick
+}
+
+// serverTimeOrNow parses the IRCv3 server-time tag (@time=...) on e, falling
+// back to the local clock when the tag is absent or malformed.
+func serverTimeOrNow(e *irc.Event) time.Time {
+	if e.Tags != nil {
+		if raw, ok := e.Tags["time"]; ok {
+			if parsed, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LLMBackend is the interface implemented by every chat completion provider
+// the bot can talk to. Implementations are responsible for translating the
+// shared Message slice into whatever wire format their API expects.
+type LLMBackend interface {
+	// Complete returns the full completion text for the given conversation.
+	Complete(ctx context.Context, messages []Message) (string, error)
+
+	// CompleteStream returns a channel of incremental text chunks. The
+	// channel is closed once the completion finishes or ctx is canceled.
+	CompleteStream(ctx context.Context, messages []Message) (<-chan string, error)
+}
+
+// ModelSetter is implemented by LLMBackend backends whose model can be
+// changed at runtime (e.g. via the "!model" command). Backends with no
+// notion of model, like PollinationsBackend, don't implement it.
+type ModelSetter interface {
+	SetModel(model string)
+}
+
+// LLMConfig holds the settings needed to construct an LLMBackend. Fields not
+// relevant to the selected backend are simply ignored.
+type LLMConfig struct {
+	Backend     string // "openai", "anthropic", or "pollinations"
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Temperature float64
+
+	// EmbeddingsModel enables MemoryStore's vector-recall step when set,
+	// using the same BaseURL/APIKey against an OpenAI-compatible
+	// /v1/embeddings endpoint.
+	EmbeddingsModel string
+}
+
+// NewLLMBackend constructs the LLMBackend selected by cfg.Backend, falling
+// back to the Pollinations text endpoint when unset.
+func NewLLMBackend(cfg LLMConfig) (LLMBackend, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	switch strings.ToLower(cfg.Backend) {
+	case "", "pollinations":
+		return &PollinationsBackend{client: client}, nil
+	case "openai":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.openai.com"
+		}
+		return &OpenAIBackend{
+			client:      client,
+			baseURL:     strings.TrimSuffix(baseURL, "/"),
+			apiKey:      cfg.APIKey,
+			model:       cfg.Model,
+			temperature: cfg.Temperature,
+		}, nil
+	case "anthropic":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.anthropic.com"
+		}
+		return &AnthropicBackend{
+			client:      client,
+			baseURL:     strings.TrimSuffix(baseURL, "/"),
+			apiKey:      cfg.APIKey,
+			model:       cfg.Model,
+			temperature: cfg.Temperature,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM_BACKEND %q", cfg.Backend)
+	}
+}
+
+// PollinationsBackend talks to the free text.pollinations.ai endpoint used by
+// the bot before pluggable backends existed. It has no notion of streaming,
+// so CompleteStream just emits the full response as a single chunk.
+type PollinationsBackend struct {
+	client *http.Client
+}
+
+func (b *PollinationsBackend) Complete(ctx context.Context, messages []Message) (string, error) {
+	prompt := buildPollinationsPrompt(messages)
+	apiURL := fmt.Sprintf("https://text.pollinations.ai/%s", url.QueryEscape(prompt))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %v", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (b *PollinationsBackend) CompleteStream(ctx context.Context, messages []Message) (<-chan string, error) {
+	text, err := b.Complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string, 1)
+	ch <- text
+	close(ch)
+	return ch, nil
+}
+
+func buildPollinationsPrompt(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			b.WriteString("System: ")
+		case "user":
+			b.WriteString("User: ")
+		case "assistant":
+			b.WriteString("Assistant: ")
+		default:
+			continue
+		}
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+	b.WriteString("Assistant: ")
+	return b.String()
+}
+
+// OpenAIBackend talks to any OpenAI-compatible /v1/chat/completions endpoint,
+// which covers OpenAI itself as well as Ollama, LM Studio, OpenRouter, and
+// vLLM when pointed at the right BaseURL.
+type OpenAIBackend struct {
+	client      *http.Client
+	baseURL     string
+	apiKey      string
+	temperature float64
+
+	mu    sync.Mutex
+	model string
+}
+
+// SetModel changes the model used for subsequent requests. Safe to call
+// while requests are in flight.
+func (b *OpenAIBackend) SetModel(model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.model = model
+}
+
+func (b *OpenAIBackend) currentModel() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.model
+}
+
+type openAIChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func (b *OpenAIBackend) newRequest(ctx context.Context, stream bool, messages []Message) (*http.Request, error) {
+	reqBody := openAIChatRequest{
+		Model:       b.currentModel(),
+		Messages:    messages,
+		Temperature: b.temperature,
+		Stream:      stream,
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/v1/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	return req, nil
+}
+
+func (b *OpenAIBackend) Complete(ctx context.Context, messages []Message) (string, error) {
+	req, err := b.newRequest(ctx, false, messages)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed openAIChatResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("API returned no choices")
+	}
+
+	return strings.TrimSpace(parsed.Choices[0].Message.Content), nil
+}
+
+func (b *OpenAIBackend) CompleteStream(ctx context.Context, messages []Message) (<-chan string, error) {
+	req, err := b.newRequest(ctx, true, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make API request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				return
+			}
+
+			var chunk openAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+
+			select {
+			case ch <- chunk.Choices[0].Delta.Content:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// AnthropicBackend talks to the Anthropic /v1/messages API.
+type AnthropicBackend struct {
+	client      *http.Client
+	baseURL     string
+	apiKey      string
+	temperature float64
+
+	mu    sync.Mutex
+	model string
+}
+
+// SetModel changes the model used for subsequent requests. Safe to call
+// while requests are in flight.
+func (b *AnthropicBackend) SetModel(model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.model = model
+}
+
+func (b *AnthropicBackend) currentModel() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.model
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float64            `json:"temperature,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// splitAnthropicMessages pulls out the leading system message (if any) since
+// the Anthropic API takes it as a top-level field rather than a message.
+func splitAnthropicMessages(messages []Message) (string, []anthropicMessage) {
+	var system string
+	converted := make([]anthropicMessage, 0, len(messages))
+
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			if system != "" {
+				system += "\n"
+			}
+			system += msg.Content
+			continue
+		}
+		converted = append(converted, anthropicMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	return system, converted
+}
+
+func (b *AnthropicBackend) newRequest(ctx context.Context, stream bool, messages []Message) (*http.Request, error) {
+	system, converted := splitAnthropicMessages(messages)
+
+	reqBody := anthropicRequest{
+		Model:       b.currentModel(),
+		System:      system,
+		Messages:    converted,
+		Temperature: b.temperature,
+		MaxTokens:   1024,
+		Stream:      stream,
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	if b.apiKey != "" {
+		req.Header.Set("x-api-key", b.apiKey)
+	}
+
+	return req, nil
+}
+
+func (b *AnthropicBackend) Complete(ctx context.Context, messages []Message) (string, error) {
+	req, err := b.newRequest(ctx, false, messages)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed anthropicResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	if len(parsed.Content) == 0 {
+		return "", fmt.Errorf("API returned no content")
+	}
+
+	return strings.TrimSpace(parsed.Content[0].Text), nil
+}
+
+func (b *AnthropicBackend) CompleteStream(ctx context.Context, messages []Message) (<-chan string, error) {
+	req, err := b.newRequest(ctx, true, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make API request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				continue
+			}
+			if event.Type != "content_block_delta" || event.Delta.Text == "" {
+				continue
+			}
+
+			select {
+			case ch <- event.Delta.Text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
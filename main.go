@@ -1,100 +1,212 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
+	"math/rand"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	irc "github.com/thoj/go-ircevent"
 )
 
-// Config holds the bot configuration
-type Config struct {
-	Server         string
-	Port           string
-	Channel        string
-	Nick           string
-	BotName        string
-	Persona        string
+const (
+	// initialReconnectBackoff and maxReconnectBackoff bound the delay an
+	// IRCBot waits between reconnect attempts after a connection error.
+	initialReconnectBackoff = time.Second
+	maxReconnectBackoff     = 5 * time.Minute
+)
+
+// ChannelConfig describes a single channel to join on a network, along with
+// its own trigger pattern for deciding when to respond to non-mention text.
+type ChannelConfig struct {
+	Name           string
 	TriggerPattern *regexp.Regexp
 }
 
+// Config holds a single network's bot configuration. A process may run
+// several of these at once under a BotManager.
+type Config struct {
+	Network  string // short name used to key conversation contexts
+	Server   string
+	Port     string
+	Channels []ChannelConfig
+	Nick     string
+	BotName  string
+	Persona  string
+	LLM      LLMConfig
+
+	// TLSInsecure opts out of certificate verification. Verification is on
+	// by default; only set this for servers with self-signed certs you
+	// trust out of band.
+	TLSInsecure bool
+
+	// SASL credentials. SASLMechanism defaults to "PLAIN" when SASLLogin is
+	// set; use "EXTERNAL" for client-certificate auth.
+	SASLLogin     string
+	SASLPassword  string
+	SASLMechanism string
+
+	// CommandPrefix introduces a dispatcher command, e.g. "!help". Defaults
+	// to "!" if empty.
+	CommandPrefix string
+	// Owners/Admins are NickServ account names or hostmasks (nick!user@host,
+	// '*' glob supported) granted elevated command roles.
+	Owners []string
+	Admins []string
+
+	// MemoryDSN is the SQLite file the shared MemoryStore persists
+	// conversation history to. Defaults to "chatbot.db" if empty.
+	MemoryDSN string
+
+	// RateLimit caps how often the bot invokes the LLM per user and per
+	// channel. Zero fields fall back to RateLimiter's defaults.
+	RateLimit RateLimitConfig
+
+	// SendIntervalMS is the minimum delay between outgoing PRIVMSGs sent by
+	// this bot's SendQueue, in milliseconds. 0 uses defaultSendInterval.
+	SendIntervalMS int
+
+	// ActionMarker is the prefix the LLM can emit to have a reply sent as a
+	// CTCP ACTION ("/me ...") instead of a plain PRIVMSG. Defaults to
+	// defaultActionMarker if empty.
+	ActionMarker string
+}
+
 // Message represents a chat message for context
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+
+	// Timestamp is set from the IRCv3 server-time tag when available, or
+	// the time the message was processed otherwise. It is not sent to LLM
+	// backends.
+	Timestamp time.Time `json:"-"`
 }
 
-// IRCBot represents our IRC bot
+// IRCBot represents our IRC bot on a single network. Conversation history is
+// kept outside the bot in a shared MemoryStore so a BotManager can key it by
+// (network, channel) across several bots.
 type IRCBot struct {
 	config     *Config
 	connection *irc.Connection
-	client     *http.Client
-	context    []Message // Keep conversation context
+	llm        LLMBackend
+	contexts   *MemoryStore
+	acl        *ACL
+	commands   *CommandDispatcher
+	limiter    *RateLimiter
+	outbox     *SendQueue
+
+	// reconnectMu guards backoff, used by Start's reconnect loop to drive
+	// reconnection with exponential backoff.
+	reconnectMu sync.Mutex
+	backoff     time.Duration
 }
 
-// NewIRCBot creates a new IRC bot instance
-func NewIRCBot(config *Config) *IRCBot {
+// NewIRCBot creates a new IRC bot instance for a single network. contexts
+// may be shared across bots so a BotManager can give every network/channel
+// pair an independent, persisted conversation history.
+func NewIRCBot(config *Config, llm LLMBackend, contexts *MemoryStore) *IRCBot {
 	conn := irc.IRC(config.Nick, config.BotName)
 	conn.VerboseCallbackHandler = false
 	conn.Debug = false
 
 	conn.UseTLS = true
 	conn.TLSConfig = &tls.Config{
-		InsecureSkipVerify: true,
+		InsecureSkipVerify: config.TLSInsecure,
 	}
 
+	// conn.Version feeds the library's own CTCP_VERSION reply, so it's
+	// answered with our branding instead of go-ircevent's default string.
+	conn.Version = "chatbot (https://github.com/jadedragon942/chatbot)"
+
+	if config.SASLLogin != "" {
+		conn.UseSASL = true
+		conn.SASLLogin = config.SASLLogin
+		conn.SASLPassword = config.SASLPassword
+		conn.SASLMech = config.SASLMechanism
+		if conn.SASLMech == "" {
+			conn.SASLMech = "PLAIN"
+		}
+	}
+
+	prefix := config.CommandPrefix
+	if prefix == "" {
+		prefix = "!"
+	}
+	acl := NewACL(config.Owners, config.Admins)
+
+	outbox := NewSendQueue(conn, time.Duration(config.SendIntervalMS)*time.Millisecond)
+	outbox.Start()
+
 	bot := &IRCBot{
 		config:     config,
 		connection: conn,
-		client:     &http.Client{Timeout: 30 * time.Second},
-		context:    make([]Message, 0, 20), // Keep last 20 messages for context
+		llm:        llm,
+		contexts:   contexts,
+		acl:        acl,
+		commands:   NewCommandDispatcher(prefix, acl),
+		limiter:    NewRateLimiter(config.RateLimit),
+		outbox:     outbox,
+		backoff:    initialReconnectBackoff,
 	}
 
-	// Add system message with persona
-	if config.Persona != "" {
-		bot.context = append(bot.context, Message{
-			Role:    "system",
-			Content: config.Persona,
-		})
-	}
+	// Registered exactly once here rather than in Connect, since Connect is
+	// called again on every reconnect and AddCallback only appends — doing
+	// this per-connect would register every handler (PRIVMSG included) once
+	// per reconnect and process each message multiple times.
+	bot.setupEventHandlers()
 
 	return bot
 }
 
-// Connect connects to the IRC server and joins the channel
+// Connect connects to the IRC server and joins the channel. Event handlers
+// are registered once in NewIRCBot, not here, so calling Connect again (from
+// reconnectWithBackoff, or a fresh reconnect attempt) never re-registers
+// them.
 func (bot *IRCBot) Connect() error {
-	// Set up event handlers
-	bot.setupEventHandlers()
-
-	// Connect to server
 	err := bot.connection.Connect(fmt.Sprintf("%s:%s", bot.config.Server, bot.config.Port))
 	if err != nil {
 		return fmt.Errorf("failed to connect to IRC server: %v", err)
 	}
 
+	// The pinned go-ircevent always resets Connection.RequestCaps to nil
+	// during its own negotiation and only re-adds "sasl" (negotiateCaps in
+	// irc.go), so setting conn.RequestCaps before Connect never reaches the
+	// wire. Request the rest of the caps we want ourselves as a second CAP
+	// round once the library's own negotiation has finished; servers accept
+	// CAP REQ at any point, not just between CAP LS and CAP END.
+	bot.connection.SendRawf("CAP REQ :%s", strings.Join(ircv3RequestedCaps, " "))
+
 	return nil
 }
 
-// setupEventHandlers configures IRC event handlers
+// setupEventHandlers configures IRC event handlers. Called exactly once per
+// IRCBot, from NewIRCBot.
 func (bot *IRCBot) setupEventHandlers() {
 	// Handle successful connection
 	bot.connection.AddCallback("001", func(e *irc.Event) {
-		log.Printf("Connected to %s", bot.config.Server)
-		bot.connection.Join(bot.config.Channel)
+		log.Printf("[%s] Connected to %s", bot.config.Network, bot.config.Server)
+
+		bot.reconnectMu.Lock()
+		bot.backoff = initialReconnectBackoff
+		bot.reconnectMu.Unlock()
+
+		for _, channel := range bot.config.Channels {
+			bot.connection.Join(channel.Name)
+		}
 	})
 
 	// Handle joining channel
 	bot.connection.AddCallback("JOIN", func(e *irc.Event) {
 		if e.Nick == bot.config.Nick {
-			log.Printf("Joined channel %s", bot.config.Channel)
+			log.Printf("[%s] Joined channel %s", bot.config.Network, e.Arguments[0])
 		}
 	})
 
@@ -103,68 +215,135 @@ func (bot *IRCBot) setupEventHandlers() {
 		bot.handleMessage(e)
 	})
 
-	// Handle disconnection
-	bot.connection.AddCallback("DISCONNECTED", func(e *irc.Event) {
-		log.Println("Disconnected from server")
+	// RunCallbacks rewrites a CTCP PRIVMSG's event.Code to CTCP_VERSION /
+	// CTCP_TIME / CTCP_PING / CTCP_ACTION / CTCP before dispatch
+	// (irc_callback.go), so CTCP never reaches the "PRIVMSG" callback above.
+	// VERSION/TIME/PING already get a reply from the library's own default
+	// callbacks (VERSION uses conn.Version, set in NewIRCBot); SOURCE has no
+	// default, and ACTION ("/me") should be unwrapped into a normal message
+	// rather than answered.
+	bot.connection.AddCallback("CTCP", func(e *irc.Event) {
+		if strings.ToUpper(e.Message()) == "SOURCE" {
+			bot.connection.Notice(e.Nick, wrapCTCP("SOURCE https://github.com/jadedragon942/chatbot"))
+		}
+	})
+	bot.connection.AddCallback("CTCP_ACTION", func(e *irc.Event) {
+		bot.handleMessage(e)
 	})
 
 	// Handle errors
 	bot.connection.AddCallback("ERROR", func(e *irc.Event) {
-		log.Printf("IRC Error: %s", e.Message())
+		log.Printf("[%s] IRC Error: %s", bot.config.Network, e.Message())
 	})
 }
 
+// reconnectWithBackoff retries Connect with exponential backoff and jitter
+// until it succeeds, then returns; the "001" handler resets bot.backoff once
+// the new connection completes registration. Only ever called from Start's
+// loop, so at most one reconnect attempt is ever in flight.
+func (bot *IRCBot) reconnectWithBackoff() {
+	for {
+		bot.reconnectMu.Lock()
+		wait := bot.backoff + time.Duration(rand.Int63n(int64(bot.backoff)/2+1))
+		bot.reconnectMu.Unlock()
+
+		log.Printf("[%s] Reconnecting in %s", bot.config.Network, wait)
+		time.Sleep(wait)
+
+		if err := bot.Connect(); err == nil {
+			return
+		} else {
+			log.Printf("[%s] Reconnect failed: %v", bot.config.Network, err)
+		}
+
+		bot.reconnectMu.Lock()
+		bot.backoff *= 2
+		if bot.backoff > maxReconnectBackoff {
+			bot.backoff = maxReconnectBackoff
+		}
+		bot.reconnectMu.Unlock()
+	}
+}
+
 // handleMessage processes incoming IRC messages
 func (bot *IRCBot) handleMessage(e *irc.Event) {
 	nick := e.Nick
 	target := e.Arguments[0]
 	message := e.Message()
 
-	// Skip messages from the bot itself
-	if nick == bot.config.Nick {
+	// Skip messages from the bot itself or from ignored users
+	if nick == bot.config.Nick || bot.acl.IsIgnored(nick) {
 		return
 	}
 
-	// Determine if this is a private message or channel message
+	// handleMessage is also the CTCP_ACTION callback (see setupEventHandlers),
+	// in which case e.Message() is already the unwrapped "/me" text.
+	isAction := e.Code == "CTCP_ACTION"
+	message = stripFormatting(message)
+
 	isPrivateMessage := target == bot.config.Nick
-	shouldRespond := isPrivateMessage || bot.shouldRespondToMessage(message)
+	responseTarget := target
+	if isPrivateMessage {
+		responseTarget = nick
+	}
+
+	identity := accountOrNick(e)
+	cmdCtx := &CommandContext{
+		Bot:      bot,
+		Event:    e,
+		Identity: identity,
+		Nick:     nick,
+		Target:   responseTarget,
+		Role:     bot.acl.Resolve(identity, e.Source),
+	}
 
+	// Prefix commands are dispatched before the AI fallback and don't
+	// require a mention or trigger match.
+	if bot.commands.Dispatch(cmdCtx, message) {
+		return
+	}
+
+	// Determine if this is a channel message that warrants an AI reply
+	shouldRespond := isPrivateMessage || bot.shouldRespondToMessage(target, message)
 	if !shouldRespond {
 		return
 	}
 
-	log.Printf("Processing message from %s: %s", nick, message)
+	if ok, notify := bot.limiter.Allow(bot.config.Network, responseTarget, identity); !ok {
+		if notify {
+			bot.sendResponse(responseTarget, "You're sending requests a bit fast — please slow down.")
+		}
+		return
+	}
+
+	log.Printf("[%s] Processing message from %s: %s", bot.config.Network, nick, message)
 
 	// Clean the message (remove bot mentions)
 	cleanMessage := bot.cleanMessage(message)
 
-	// Get AI response
-	response, err := bot.getAIResponse(cleanMessage, nick)
-	if err != nil {
-		log.Printf("Error getting AI response: %v", err)
-		return
-	}
+	// server-time lets us timestamp context messages from the server's
+	// clock instead of the time we happened to process them.
+	timestamp := serverTimeOrNow(e)
 
-	// Send response back
-	responseTarget := target
-	if isPrivateMessage {
-		responseTarget = nick
+	// Stream the AI response, flushing complete sentences to IRC as they
+	// arrive instead of waiting for the whole reply.
+	if err := bot.streamAIResponse(responseTarget, cleanMessage, identity, timestamp, isAction); err != nil {
+		log.Printf("[%s] Error getting AI response: %v", bot.config.Network, err)
 	}
-
-	// Split long responses into multiple lines
-	bot.sendResponse(responseTarget, response)
 }
 
 // shouldRespondToMessage determines if the bot should respond to a channel message
-func (bot *IRCBot) shouldRespondToMessage(message string) bool {
+func (bot *IRCBot) shouldRespondToMessage(channel, message string) bool {
 	// Always respond if mentioned by name
 	if strings.Contains(strings.ToLower(message), strings.ToLower(bot.config.Nick)) {
 		return true
 	}
 
-	// Use trigger pattern if configured
-	if bot.config.TriggerPattern != nil {
-		return bot.config.TriggerPattern.MatchString(message)
+	// Use the per-channel trigger pattern if configured
+	for _, ch := range bot.config.Channels {
+		if ch.Name == channel && ch.TriggerPattern != nil {
+			return ch.TriggerPattern.MatchString(message)
+		}
 	}
 
 	return false
@@ -183,80 +362,96 @@ func (bot *IRCBot) cleanMessage(message string) string {
 	return cleanMsg
 }
 
-// getAIResponse gets a response from Pollinations.ai
-func (bot *IRCBot) getAIResponse(message, fromNick string) (string, error) {
-	// Add user message to context
-	userMessage := Message{
-		Role:    "user",
-		Content: fmt.Sprintf("%s: %s", fromNick, message),
+// streamAIResponse asks the configured LLMBackend for a completion and
+// flushes it to target as soon as complete sentences become available,
+// rather than buffering the whole reply before sending anything. isAction
+// marks message as having arrived via CTCP ACTION ("/me"), so it's recorded
+// in context the same way a human reader would describe it.
+func (bot *IRCBot) streamAIResponse(target, message, identity string, timestamp time.Time, isAction bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Retrieve before persisting the current turn: RetrieveRelevant scans
+	// the same (network, channel)'s stored turns, and the current message
+	// hasn't been embedded and stored yet, so it can't show up as its own
+	// "relevant" match.
+	relevant := bot.contexts.RetrieveRelevant(ctx, bot.config.Network, target, message)
+
+	userLine := fmt.Sprintf("%s: %s", identity, message)
+	if isAction {
+		userLine = fmt.Sprintf("* %s %s", identity, message)
 	}
+	bot.addToContext(target, identity, Message{
+		Role:      "user",
+		Content:   userLine,
+		Timestamp: timestamp,
+	})
 
-	bot.addToContext(userMessage)
-
-	// Build the prompt from context
-	prompt := bot.buildPrompt()
-
-	// Make API request to Pollinations.ai
-	// Using URL encoding for the prompt
-	encodedPrompt := url.QueryEscape(prompt)
-	apiUrl := fmt.Sprintf("https://text.pollinations.ai/%s", encodedPrompt)
+	prompt := bot.contexts.Get(bot.config.Network, target)
+	if len(relevant) > 0 {
+		prompt = injectRelevantHistory(prompt, relevant)
+	}
 
-	resp, err := bot.client.Get(apiUrl)
+	chunks, err := bot.llm.CompleteStream(ctx, prompt)
 	if err != nil {
-		return "", fmt.Errorf("failed to make API request: %v", err)
+		return fmt.Errorf("failed to start completion: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
+	var full strings.Builder
+	var pending strings.Builder
+	sentenceEnd := regexp.MustCompile(`[.!?]+\s+`)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
+	for chunk := range chunks {
+		full.WriteString(chunk)
+		pending.WriteString(chunk)
 
-	fmt.Printf("body -> %s\n", string(body))
+		for {
+			loc := sentenceEnd.FindStringIndex(pending.String())
+			if loc == nil {
+				break
+			}
+			sentence := bot.cleanAIResponse(pending.String()[:loc[1]])
+			rest := pending.String()[loc[1]:]
+			pending.Reset()
+			pending.WriteString(rest)
+
+			if sentence != "" {
+				bot.sendResponse(target, sentence)
+			}
+		}
+	}
 
-	// Pollinations.ai returns plain text, not JSON
-	aiResponse := strings.TrimSpace(string(body))
+	if remaining := bot.cleanAIResponse(pending.String()); remaining != "" {
+		bot.sendResponse(target, remaining)
+	}
 
-	// Clean up common artifacts
-	aiResponse = bot.cleanAIResponse(aiResponse)
+	aiResponse := bot.cleanAIResponse(full.String())
+	if aiResponse == "" {
+		return fmt.Errorf("empty completion")
+	}
 
-	// Add AI response to context
-	bot.addToContext(Message{
+	bot.addToContext(target, bot.config.Nick, Message{
 		Role:    "assistant",
 		Content: aiResponse,
 	})
 
-	return aiResponse, nil
+	return nil
 }
 
-// buildPrompt creates a prompt from the conversation context
-func (bot *IRCBot) buildPrompt() string {
-	var promptBuilder strings.Builder
-
-	for _, msg := range bot.context {
-		switch msg.Role {
-		case "system":
-			promptBuilder.WriteString("System: ")
-			promptBuilder.WriteString(msg.Content)
-			promptBuilder.WriteString("\n")
-		case "user":
-			promptBuilder.WriteString("User: ")
-			promptBuilder.WriteString(msg.Content)
-			promptBuilder.WriteString("\n")
-		case "assistant":
-			promptBuilder.WriteString("Assistant: ")
-			promptBuilder.WriteString(msg.Content)
-			promptBuilder.WriteString("\n")
-		}
+// injectRelevantHistory splices retrieval-augmented turns into prompt,
+// right after the leading system message (if any), so they read as extra
+// background rather than the most recent turns.
+func injectRelevantHistory(prompt, relevant []Message) []Message {
+	insertAt := 0
+	if len(prompt) > 0 && prompt[0].Role == "system" {
+		insertAt = 1
 	}
 
-	promptBuilder.WriteString("Assistant: ")
-	return promptBuilder.String()
+	out := make([]Message, 0, len(prompt)+len(relevant))
+	out = append(out, prompt[:insertAt]...)
+	out = append(out, relevant...)
+	out = append(out, prompt[insertAt:]...)
+	return out
 }
 
 // cleanAIResponse cleans up the AI response
@@ -279,25 +474,36 @@ func (bot *IRCBot) cleanAIResponse(response string) string {
 	return strings.TrimSpace(response)
 }
 
-// addToContext adds a message to the conversation context with size limiting
-func (bot *IRCBot) addToContext(message Message) {
-	bot.context = append(bot.context, message)
-
-	// Keep context size reasonable (keep system message + last 18 messages)
-	if len(bot.context) > 19 {
-		// Keep system message at index 0, remove oldest user/assistant messages
-		systemMsg := bot.context[0]
-		bot.context = append([]Message{systemMsg}, bot.context[len(bot.context)-18:]...)
-	}
+// addToContext persists a message to the conversation history for the given
+// channel (or nick, for a private conversation), delegating persistence,
+// summarization, and size limiting to the shared MemoryStore.
+func (bot *IRCBot) addToContext(channel, nick string, message Message) {
+	bot.contexts.Append(bot.config.Network, channel, nick, bot.config.Persona, message)
 }
 
-// sendResponse sends a response to IRC, handling long messages
+// sendResponse sends a response to IRC, handling long messages. Chunks are
+// handed to the bot's SendQueue rather than sent directly, so splitting a
+// long reply can't flood the server the way back-to-back Privmsg calls would.
+// A response beginning with the configured ActionMarker is sent as a single
+// CTCP ACTION ("/me ...") instead of a plain PRIVMSG.
 func (bot *IRCBot) sendResponse(target, response string) {
+	marker := bot.config.ActionMarker
+	if marker == "" {
+		marker = defaultActionMarker
+	}
+	if trimmed := strings.TrimSpace(response); strings.HasPrefix(trimmed, marker) {
+		action := markdownToIRC(strings.TrimSpace(strings.TrimPrefix(trimmed, marker)))
+		bot.outbox.Enqueue(target, wrapCTCP("ACTION "+action))
+		return
+	}
+
+	response = markdownToIRC(response)
+
 	// Split long messages
 	maxLength := 400 // Leave some room for IRC protocol overhead
 
 	if len(response) <= maxLength {
-		bot.connection.Privmsg(target, response)
+		bot.outbox.Enqueue(target, response)
 		return
 	}
 
@@ -313,8 +519,7 @@ func (bot *IRCBot) sendResponse(target, response string) {
 			currentMsg += sentence
 		} else {
 			if currentMsg != "" {
-				bot.connection.Privmsg(target, currentMsg)
-				time.Sleep(500 * time.Millisecond) // Small delay between messages
+				bot.outbox.Enqueue(target, currentMsg)
 			}
 
 			// If single sentence is too long, split by words
@@ -329,8 +534,7 @@ func (bot *IRCBot) sendResponse(target, response string) {
 						currentMsg += word
 					} else {
 						if currentMsg != "" {
-							bot.connection.Privmsg(target, currentMsg)
-							time.Sleep(500 * time.Millisecond)
+							bot.outbox.Enqueue(target, currentMsg)
 						}
 						currentMsg = word
 					}
@@ -342,32 +546,116 @@ func (bot *IRCBot) sendResponse(target, response string) {
 	}
 
 	if currentMsg != "" {
-		bot.connection.Privmsg(target, currentMsg)
+		bot.outbox.Enqueue(target, currentMsg)
 	}
 }
 
 // Start starts the bot's main loop
+// Start blocks for the process lifetime, driving reconnection itself with
+// exponential backoff whenever the connection reports an error. It doesn't
+// use Connection.Loop: that drives reconnection off a "DISCONNECTED" event
+// the pinned go-ircevent never emits, and otherwise retries at a fixed 60s
+// interval with no backoff/jitter. Connection.ErrorChan is what Loop itself
+// actually reads from, so that's the real signal to reconnect on.
 func (bot *IRCBot) Start() {
-	bot.connection.Loop()
+	errChan := bot.connection.ErrorChan()
+	for err := range errChan {
+		if !bot.connection.Connected() {
+			return // Stop was called; this is the final error from Disconnect.
+		}
+
+		log.Printf("[%s] Disconnected: %v", bot.config.Network, err)
+		bot.reconnectWithBackoff()
+		errChan = bot.connection.ErrorChan()
+	}
 }
 
 // Stop disconnects the bot
 func (bot *IRCBot) Stop() {
 	bot.connection.Quit()
 	bot.connection.Disconnect()
+	bot.outbox.Stop()
 }
 
 func main() {
-	// Configuration - you can modify these or use environment variables
+	configs, err := loadConfigs()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	llmCfg := configs[0].LLM
+	llm, err := NewLLMBackend(llmCfg)
+	if err != nil {
+		log.Printf("Failed to initialize LLM backend %q, falling back to Pollinations: %v", llmCfg.Backend, err)
+		llm, _ = NewLLMBackend(LLMConfig{Backend: "pollinations"})
+	}
+
+	var embeddings *EmbeddingsClient
+	if llmCfg.EmbeddingsModel != "" {
+		embeddings = NewEmbeddingsClient(llmCfg.BaseURL, llmCfg.APIKey, llmCfg.EmbeddingsModel)
+	}
+
+	memoryDSN := configs[0].MemoryDSN
+	if memoryDSN == "" {
+		memoryDSN = "chatbot.db"
+	}
+	store, err := NewMemoryStore(MemoryStoreOptions{DSN: memoryDSN, LLM: llm, Embeddings: embeddings})
+	if err != nil {
+		log.Fatalf("Failed to open memory store: %v", err)
+	}
+
+	manager := NewBotManager(configs, llm, store)
+
+	log.Printf("Starting IRC bot on %d network(s)...", len(configs))
+	for _, cfg := range configs {
+		log.Printf("[%s] %s:%s, channels: %v", cfg.Network, cfg.Server, cfg.Port, channelNames(cfg.Channels))
+	}
+
+	// Run blocks forever, reconnecting each network independently.
+	manager.Run()
+}
+
+// loadConfigs returns one Config per network. If CONFIG_FILE is set it is
+// read as YAML describing multiple networks; otherwise a single network is
+// built from the legacy IRC_* / BOT_PERSONA / LLM_* environment variables.
+func loadConfigs() ([]*Config, error) {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return LoadNetworkConfigs(path)
+	}
+
 	config := &Config{
+		Network: getEnvOrDefault("IRC_NETWORK", "default"),
 		Server:  getEnvOrDefault("IRC_SERVER", "irc.h4ks.com"),
 		Port:    getEnvOrDefault("IRC_PORT", "6697"),
-		Channel: getEnvOrDefault("IRC_CHANNEL", "#lobby"),
 		Nick:    getEnvOrDefault("IRC_NICK", "SteveBot"),
 		BotName: getEnvOrDefault("IRC_BOTNAME", "Very cool and helpful bot"),
 		Persona: getEnvOrDefault("BOT_PERSONA", "You are a helpful and friendly IRC bot named Steve. Keep responses concise and engaging. You have a casual, slightly witty personality. Always be respectful and helpful."),
+		LLM: LLMConfig{
+			Backend:         getEnvOrDefault("LLM_BACKEND", "pollinations"),
+			BaseURL:         getEnvOrDefault("LLM_BASE_URL", ""),
+			APIKey:          getEnvOrDefault("LLM_API_KEY", ""),
+			Model:           getEnvOrDefault("LLM_MODEL", "gpt-4o-mini"),
+			Temperature:     getEnvFloatOrDefault("LLM_TEMPERATURE", 0.7),
+			EmbeddingsModel: getEnvOrDefault("LLM_EMBEDDINGS_MODEL", ""),
+		},
+		TLSInsecure:   getEnvOrDefault("IRC_TLS_INSECURE", "") == "true",
+		SASLLogin:     getEnvOrDefault("IRC_SASL_LOGIN", ""),
+		SASLPassword:  getEnvOrDefault("IRC_SASL_PASSWORD", ""),
+		SASLMechanism: getEnvOrDefault("IRC_SASL_MECHANISM", ""),
+		CommandPrefix: getEnvOrDefault("BOT_COMMAND_PREFIX", "!"),
+		Owners:        splitEnvList("BOT_OWNERS"),
+		Admins:        splitEnvList("BOT_ADMINS"),
+		MemoryDSN:     getEnvOrDefault("MEMORY_DSN", "chatbot.db"),
+		RateLimit: RateLimitConfig{
+			UserPerMinute:    getEnvIntOrDefault("RATE_LIMIT_USER_PER_MINUTE", defaultUserPerMinute),
+			ChannelPerMinute: getEnvIntOrDefault("RATE_LIMIT_CHANNEL_PER_MINUTE", defaultChannelPerMinute),
+		},
+		SendIntervalMS: getEnvIntOrDefault("SEND_INTERVAL_MS", int(defaultSendInterval/time.Millisecond)),
+		ActionMarker:   getEnvOrDefault("BOT_ACTION_MARKER", defaultActionMarker),
 	}
 
+	channel := ChannelConfig{Name: getEnvOrDefault("IRC_CHANNEL", "#lobby")}
+
 	// Optional: Set up trigger pattern for channel messages
 	// This example responds to messages containing "bot" or starting with "!"
 	triggerPattern := getEnvOrDefault("TRIGGER_PATTERN", `(?i)(steve|^!)`)
@@ -376,25 +664,38 @@ func main() {
 		if err != nil {
 			log.Printf("Invalid trigger pattern: %v", err)
 		} else {
-			config.TriggerPattern = pattern
+			channel.TriggerPattern = pattern
 		}
 	}
+	config.Channels = []ChannelConfig{channel}
 
-	// Create and start bot
-	bot := NewIRCBot(config)
+	return []*Config{config}, nil
+}
 
-	log.Printf("Starting IRC bot...")
-	log.Printf("Server: %s:%s", config.Server, config.Port)
-	log.Printf("Channel: %s", config.Channel)
-	log.Printf("Nick: %s", config.Nick)
+// channelNames extracts channel names for logging.
+func channelNames(channels []ChannelConfig) []string {
+	names := make([]string, len(channels))
+	for i, ch := range channels {
+		names[i] = ch.Name
+	}
+	return names
+}
 
-	err := bot.Connect()
-	if err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+// splitEnvList reads key as a comma-separated list, returning nil if unset.
+func splitEnvList(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
 	}
 
-	// Start the bot (this will block)
-	bot.Start()
+	parts := strings.Split(value, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
 }
 
 // getEnvOrDefault gets an environment variable or returns a default value
@@ -404,3 +705,37 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvFloatOrDefault gets an environment variable parsed as a float64, or
+// returns a default value if unset or unparsable.
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid float for %s: %v", key, err)
+		return defaultValue
+	}
+
+	return parsed
+}
+
+// getEnvIntOrDefault gets an environment variable parsed as an int, or
+// returns a default value if unset or unparsable.
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid int for %s: %v", key, err)
+		return defaultValue
+	}
+
+	return parsed
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// networkConfigFile is the on-disk YAML shape for a multi-network config, as
+// pointed to by the CONFIG_FILE env var.
+type networkConfigFile struct {
+	Networks []networkFileEntry `yaml:"networks"`
+	LLM      llmConfigFile      `yaml:"llm"`
+	Memory   memoryConfigFile   `yaml:"memory"`
+}
+
+type networkFileEntry struct {
+	Name           string             `yaml:"name"`
+	Server         string             `yaml:"server"`
+	Port           string             `yaml:"port"`
+	Nick           string             `yaml:"nick"`
+	BotName        string             `yaml:"botname"`
+	Persona        string             `yaml:"persona"`
+	Channels       []channelFileEntry `yaml:"channels"`
+	TLSInsecure    bool               `yaml:"tls_insecure"`
+	SASL           saslFileEntry      `yaml:"sasl"`
+	CommandPrefix  string             `yaml:"command_prefix"`
+	Owners         []string           `yaml:"owners"`
+	Admins         []string           `yaml:"admins"`
+	RateLimit      rateLimitFileEntry `yaml:"rate_limit"`
+	SendIntervalMS int                `yaml:"send_interval_ms"`
+	ActionMarker   string             `yaml:"action_marker"`
+}
+
+// rateLimitFileEntry is the YAML shape of a network's rate_limit block. Zero
+// fields fall back to RateLimiter's defaults.
+type rateLimitFileEntry struct {
+	UserPerMinute    int `yaml:"user_per_minute"`
+	ChannelPerMinute int `yaml:"channel_per_minute"`
+}
+
+type saslFileEntry struct {
+	Login     string `yaml:"login"`
+	Password  string `yaml:"password"`
+	Mechanism string `yaml:"mechanism"`
+}
+
+type channelFileEntry struct {
+	Name    string `yaml:"name"`
+	Trigger string `yaml:"trigger"`
+}
+
+type llmConfigFile struct {
+	Backend         string  `yaml:"backend"`
+	BaseURL         string  `yaml:"base_url"`
+	APIKey          string  `yaml:"api_key"`
+	Model           string  `yaml:"model"`
+	Temperature     float64 `yaml:"temperature"`
+	EmbeddingsModel string  `yaml:"embeddings_model"`
+}
+
+// memoryConfigFile configures the shared MemoryStore. It lives alongside
+// networks/llm at the top level of the config file since memory is process-
+// wide, not per-network.
+type memoryConfigFile struct {
+	DSN string `yaml:"dsn"`
+}
+
+// LoadNetworkConfigs reads a YAML file listing one or more networks and
+// returns a Config per network, sharing a single LLMConfig across all of
+// them.
+func LoadNetworkConfigs(path string) ([]*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var file networkConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	llmCfg := LLMConfig{
+		Backend:         file.LLM.Backend,
+		BaseURL:         file.LLM.BaseURL,
+		APIKey:          file.LLM.APIKey,
+		Model:           file.LLM.Model,
+		Temperature:     file.LLM.Temperature,
+		EmbeddingsModel: file.LLM.EmbeddingsModel,
+	}
+
+	configs := make([]*Config, 0, len(file.Networks))
+	for _, net := range file.Networks {
+		channels := make([]ChannelConfig, 0, len(net.Channels))
+		for _, ch := range net.Channels {
+			cc := ChannelConfig{Name: ch.Name}
+			if ch.Trigger != "" {
+				pattern, err := regexp.Compile(ch.Trigger)
+				if err != nil {
+					return nil, fmt.Errorf("invalid trigger pattern for %s/%s: %v", net.Name, ch.Name, err)
+				}
+				cc.TriggerPattern = pattern
+			}
+			channels = append(channels, cc)
+		}
+
+		configs = append(configs, &Config{
+			Network:       net.Name,
+			Server:        net.Server,
+			Port:          net.Port,
+			Channels:      channels,
+			Nick:          net.Nick,
+			BotName:       net.BotName,
+			Persona:       net.Persona,
+			LLM:           llmCfg,
+			TLSInsecure:   net.TLSInsecure,
+			SASLLogin:     net.SASL.Login,
+			SASLPassword:  net.SASL.Password,
+			SASLMechanism: net.SASL.Mechanism,
+			CommandPrefix: net.CommandPrefix,
+			Owners:        net.Owners,
+			Admins:        net.Admins,
+			MemoryDSN:     file.Memory.DSN,
+			RateLimit: RateLimitConfig{
+				UserPerMinute:    net.RateLimit.UserPerMinute,
+				ChannelPerMinute: net.RateLimit.ChannelPerMinute,
+			},
+			SendIntervalMS: net.SendIntervalMS,
+			ActionMarker:   net.ActionMarker,
+		})
+	}
+
+	return configs, nil
+}
+
+// BotManager owns a set of IRCBot instances, one per network, sharing a
+// single LLM backend and MemoryStore between them.
+type BotManager struct {
+	bots []*IRCBot
+}
+
+// NewBotManager builds one IRCBot per config, wiring them all to the same
+// llm backend and a shared MemoryStore so per-channel conversations stay
+// independent across networks while persisting to one database.
+func NewBotManager(configs []*Config, llm LLMBackend, store *MemoryStore) *BotManager {
+	manager := &BotManager{bots: make([]*IRCBot, 0, len(configs))}
+	for _, cfg := range configs {
+		manager.bots = append(manager.bots, NewIRCBot(cfg, llm, store))
+	}
+
+	return manager
+}
+
+// Run connects every managed bot and blocks until all of them stop. Once
+// connected, each bot reconnects itself with exponential backoff whenever it
+// gets disconnected (see IRCBot.reconnectWithBackoff); Run only needs to
+// retry the initial connection attempt.
+func (m *BotManager) Run() {
+	var wg sync.WaitGroup
+
+	for _, bot := range m.bots {
+		wg.Add(1)
+		go func(bot *IRCBot) {
+			defer wg.Done()
+			runWithReconnect(bot)
+		}(bot)
+	}
+
+	wg.Wait()
+}
+
+// runWithReconnect retries Connect with exponential backoff and jitter until
+// the initial connection succeeds, then calls Start, which blocks for the
+// process lifetime; the bot handles reconnecting itself after that point.
+func runWithReconnect(bot *IRCBot) {
+	backoff := time.Second
+
+	for {
+		if err := bot.Connect(); err != nil {
+			log.Printf("[%s] Connect failed: %v", bot.config.Network, err)
+
+			jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+			wait := backoff + jitter
+			log.Printf("[%s] Retrying initial connect in %s", bot.config.Network, wait)
+			time.Sleep(wait)
+
+			backoff *= 2
+			if backoff > 5*time.Minute {
+				backoff = 5 * time.Minute
+			}
+			continue
+		}
+
+		bot.Start() // blocks for the process lifetime
+		return
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// defaultRecentTurns is how many of the newest turns are always kept
+	// verbatim in a channel's working context, mirroring the old in-memory
+	// sliding window.
+	defaultRecentTurns = 18
+
+	// defaultSummaryThreshold is the rough token budget (content length / 4)
+	// a channel's un-summarized history can reach before it gets rolled up.
+	defaultSummaryThreshold = 3000
+
+	// defaultRetrievalTopK is how many extra past turns the embeddings step
+	// injects as additional context, when enabled.
+	defaultRetrievalTopK = 3
+)
+
+// MemoryStore persists every conversation turn to SQLite keyed by
+// (network, channel, nick, ts), replacing the old in-memory sliding window.
+// Once a channel's un-summarized history crosses summaryThreshold estimated
+// tokens, the oldest turns are rolled up into a summary system message via
+// the LLM backend. If embeddings is set, RetrieveRelevant can also surface
+// semantically similar older turns that fell outside the working window.
+type MemoryStore struct {
+	mu               sync.Mutex
+	db               *sql.DB
+	llm              LLMBackend
+	summaryThreshold int
+	embeddings       *EmbeddingsClient
+	retrievalTopK    int
+}
+
+// MemoryStoreOptions configures NewMemoryStore.
+type MemoryStoreOptions struct {
+	DSN              string // sqlite DSN, e.g. "chatbot.db"
+	LLM              LLMBackend
+	SummaryThreshold int               // estimated tokens; 0 uses the default
+	Embeddings       *EmbeddingsClient // nil disables vector recall
+	RetrievalTopK    int               // 0 uses the default
+}
+
+// NewMemoryStore opens (creating if necessary) the SQLite database at
+// opts.DSN and ensures its schema exists.
+func NewMemoryStore(opts MemoryStoreOptions) (*MemoryStore, error) {
+	db, err := sql.Open("sqlite3", opts.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open memory store: %v", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		network TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		nick TEXT NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL,
+		ts DATETIME NOT NULL,
+		embedding BLOB
+	);
+	CREATE INDEX IF NOT EXISTS idx_messages_channel_ts ON messages(network, channel, ts);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create memory store schema: %v", err)
+	}
+
+	threshold := opts.SummaryThreshold
+	if threshold <= 0 {
+		threshold = defaultSummaryThreshold
+	}
+	topK := opts.RetrievalTopK
+	if topK <= 0 {
+		topK = defaultRetrievalTopK
+	}
+
+	return &MemoryStore{
+		db:               db,
+		llm:              opts.LLM,
+		summaryThreshold: threshold,
+		embeddings:       opts.Embeddings,
+		retrievalTopK:    topK,
+	}, nil
+}
+
+// Close releases the underlying database handle.
+func (s *MemoryStore) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the working context for (network, channel): any summary /
+// persona system message followed by the stored turns, oldest first.
+func (s *MemoryStore) Get(network, channel string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.loadAll(network, channel)
+	if err != nil {
+		log.Printf("memory: failed to load %s/%s: %v", network, channel, err)
+		return nil
+	}
+	return messages
+}
+
+// Append persists message for (network, channel, nick), seeding the channel
+// with persona as its first system message if this is the first turn seen,
+// then rolls old turns into a summary once the channel's history crosses
+// summaryThreshold. The embedding call, if any, runs before s.mu is taken so
+// a slow embeddings endpoint can't serialize every channel on its latency.
+func (s *MemoryStore) Append(network, channel, nick, persona string, message Message) {
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+
+	embedding := s.embed(message.Role, message.Content)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.count(network, channel)
+	if err != nil {
+		log.Printf("memory: failed to count %s/%s: %v", network, channel, err)
+		return
+	}
+
+	if count == 0 && persona != "" {
+		if err := s.insert(network, channel, "", "system", persona, message.Timestamp.Add(-time.Second), nil); err != nil {
+			log.Printf("memory: failed to seed persona for %s/%s: %v", network, channel, err)
+		}
+	}
+
+	if err := s.insert(network, channel, nick, message.Role, message.Content, message.Timestamp, embedding); err != nil {
+		log.Printf("memory: failed to store message for %s/%s: %v", network, channel, err)
+		return
+	}
+
+	s.maybeSummarize(network, channel)
+}
+
+// Clear wipes all stored history for (network, channel), e.g. in response
+// to a "!context clear" command.
+func (s *MemoryStore) Clear(network, channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM messages WHERE network = ? AND channel = ?`, network, channel); err != nil {
+		log.Printf("memory: failed to clear %s/%s: %v", network, channel, err)
+	}
+}
+
+// ResetPersona replaces the system message for (network, channel) with
+// persona, keeping the rest of the history intact.
+func (s *MemoryStore) ResetPersona(network, channel, persona string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM messages WHERE network = ? AND channel = ? AND role = 'system'`, network, channel); err != nil {
+		log.Printf("memory: failed to clear persona for %s/%s: %v", network, channel, err)
+		return
+	}
+
+	// Sort before every real turn by backdating it well before "now".
+	if err := s.insert(network, channel, "", "system", persona, time.Unix(0, 0), nil); err != nil {
+		log.Printf("memory: failed to set persona for %s/%s: %v", network, channel, err)
+	}
+}
+
+// RetrieveRelevant embeds query and returns the topK most semantically
+// similar past turns stored for (network, channel), skipping system
+// messages. Returns nil without error if embeddings aren't configured.
+func (s *MemoryStore) RetrieveRelevant(ctx context.Context, network, channel, query string) []Message {
+	if s.embeddings == nil {
+		return nil
+	}
+
+	queryVec, err := s.embeddings.Embed(ctx, query)
+	if err != nil {
+		log.Printf("memory: failed to embed query for %s/%s: %v", network, channel, err)
+		return nil
+	}
+
+	type scored struct {
+		message Message
+		score   float64
+	}
+
+	s.mu.Lock()
+	rows, err := s.db.Query(`SELECT role, content, ts, embedding FROM messages WHERE network = ? AND channel = ? AND role != 'system' AND embedding IS NOT NULL`, network, channel)
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("memory: failed to query embeddings for %s/%s: %v", network, channel, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var candidates []scored
+	for rows.Next() {
+		var msg Message
+		var blob []byte
+		if err := rows.Scan(&msg.Role, &msg.Content, &msg.Timestamp, &blob); err != nil {
+			continue
+		}
+
+		var vec []float64
+		if err := json.Unmarshal(blob, &vec); err != nil {
+			continue
+		}
+
+		candidates = append(candidates, scored{message: msg, score: cosineSimilarity(queryVec, vec)})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	topK := s.retrievalTopK
+	if topK > len(candidates) {
+		topK = len(candidates)
+	}
+
+	out := make([]Message, topK)
+	for i := 0; i < topK; i++ {
+		out[i] = candidates[i].message
+	}
+	return out
+}
+
+func (s *MemoryStore) count(network, channel string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE network = ? AND channel = ?`, network, channel).Scan(&n)
+	return n, err
+}
+
+// embed computes the embedding for content, if embeddings are configured and
+// role isn't "system". Callers do this before taking s.mu so a slow
+// embeddings endpoint doesn't serialize the whole store.
+func (s *MemoryStore) embed(role, content string) []byte {
+	if s.embeddings == nil || role == "system" {
+		return nil
+	}
+
+	vec, err := s.embeddings.Embed(context.Background(), content)
+	if err != nil {
+		log.Printf("memory: failed to embed message: %v", err)
+		return nil
+	}
+
+	encoded, err := json.Marshal(vec)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+func (s *MemoryStore) insert(network, channel, nick, role, content string, ts time.Time, embedding []byte) error {
+	_, err := s.db.Exec(
+		`INSERT INTO messages (network, channel, nick, role, content, ts, embedding) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		network, channel, nick, role, content, ts, embedding,
+	)
+	return err
+}
+
+// storedMessage pairs a Message with its raw embedding blob, as persisted,
+// so maybeSummarize can carry embeddings through a DELETE+INSERT without
+// re-deriving them.
+type storedMessage struct {
+	msg       Message
+	embedding []byte
+}
+
+func (s *MemoryStore) loadAllRaw(network, channel string) ([]storedMessage, error) {
+	rows, err := s.db.Query(`SELECT role, content, ts, embedding FROM messages WHERE network = ? AND channel = ? ORDER BY ts ASC, id ASC`, network, channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []storedMessage
+	for rows.Next() {
+		var sm storedMessage
+		if err := rows.Scan(&sm.msg.Role, &sm.msg.Content, &sm.msg.Timestamp, &sm.embedding); err != nil {
+			return nil, err
+		}
+		out = append(out, sm)
+	}
+	return out, rows.Err()
+}
+
+func (s *MemoryStore) loadAll(network, channel string) ([]Message, error) {
+	raw, err := s.loadAllRaw(network, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(raw))
+	for i, sm := range raw {
+		messages[i] = sm.msg
+	}
+	return messages, nil
+}
+
+// maybeSummarize rolls the oldest turns for (network, channel) into a
+// single summary system message once the channel's estimated token count
+// crosses summaryThreshold, keeping only defaultRecentTurns verbatim turns
+// plus the summary. Must be called with s.mu held. The persona system
+// message, if any, is preserved across the rollup instead of being rolled
+// into the summary or dropped. Retained turns carry their already-computed
+// embeddings through the DELETE+INSERT instead of re-embedding unchanged
+// content.
+func (s *MemoryStore) maybeSummarize(network, channel string) {
+	if s.llm == nil {
+		return
+	}
+
+	raw, err := s.loadAllRaw(network, channel)
+	if err != nil {
+		log.Printf("memory: failed to load %s/%s for summarization: %v", network, channel, err)
+		return
+	}
+
+	messages := make([]Message, len(raw))
+	for i, sm := range raw {
+		messages[i] = sm.msg
+	}
+
+	if estimateTokens(messages) < s.summaryThreshold {
+		return
+	}
+
+	start := 0
+	var persona *storedMessage
+	if len(raw) > 0 && raw[0].msg.Role == "system" {
+		start = 1
+		persona = &raw[0]
+	}
+
+	boundary := len(raw) - defaultRecentTurns
+	if boundary <= start {
+		return // not enough history past the system message to roll up
+	}
+
+	old := messages[start:boundary]
+	recent := raw[boundary:]
+
+	summary, err := s.summarize(old)
+	if err != nil {
+		log.Printf("memory: failed to summarize %s/%s: %v", network, channel, err)
+		return
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM messages WHERE network = ? AND channel = ?`, network, channel); err != nil {
+		log.Printf("memory: failed to clear %s/%s before re-summarizing: %v", network, channel, err)
+		return
+	}
+
+	base := time.Unix(0, 0)
+	if persona != nil {
+		// Keep the persona ahead of the summary so it still sorts first.
+		if err := s.insert(network, channel, "", "system", persona.msg.Content, base.Add(-time.Second), persona.embedding); err != nil {
+			log.Printf("memory: failed to restore persona for %s/%s: %v", network, channel, err)
+			return
+		}
+	}
+
+	if err := s.insert(network, channel, "", "system", summary, base, nil); err != nil {
+		log.Printf("memory: failed to store summary for %s/%s: %v", network, channel, err)
+		return
+	}
+
+	for i, sm := range recent {
+		// Re-insert with ts offsets preserved so ordering survives the
+		// DELETE+INSERT round trip, carrying the original embedding forward
+		// instead of re-deriving it.
+		if err := s.insert(network, channel, "", sm.msg.Role, sm.msg.Content, base.Add(time.Duration(i+1)*time.Second), sm.embedding); err != nil {
+			log.Printf("memory: failed to restore turn for %s/%s: %v", network, channel, err)
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) summarize(turns []Message) (string, error) {
+	var transcript string
+	for _, msg := range turns {
+		transcript += fmt.Sprintf("%s: %s\n", msg.Role, msg.Content)
+	}
+
+	return s.llm.Complete(context.Background(), []Message{
+		{Role: "system", Content: "Summarize the following IRC conversation history in at most 300 tokens. Preserve names, facts, and decisions; drop small talk."},
+		{Role: "user", Content: transcript},
+	})
+}
+
+// estimateTokens is a rough chars/4 estimate, good enough to decide when to
+// roll up history without pulling in a real tokenizer.
+func estimateTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += len(msg.Content) / 4
+	}
+	return total
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	irc "github.com/thoj/go-ircevent"
+)
+
+// defaultSendInterval mirrors the delay sendResponse used to sleep between
+// split chunks; it's also a reasonable default flood-control interval for a
+// server that doesn't publish its own PRIVMSG rate limit.
+const defaultSendInterval = 500 * time.Millisecond
+
+// outboundMessage is one queued PRIVMSG.
+type outboundMessage struct {
+	target  string
+	message string
+}
+
+// SendQueue serializes outgoing PRIVMSGs through a single goroutine ticking
+// at interval, so a long or fast-split reply can't flood the server the way
+// firing off Privmsg calls in a tight loop would.
+type SendQueue struct {
+	conn     *irc.Connection
+	interval time.Duration
+	messages chan outboundMessage
+	stop     chan struct{}
+}
+
+// NewSendQueue builds a SendQueue that sends through conn at most once per
+// interval. interval <= 0 uses defaultSendInterval.
+func NewSendQueue(conn *irc.Connection, interval time.Duration) *SendQueue {
+	if interval <= 0 {
+		interval = defaultSendInterval
+	}
+
+	return &SendQueue{
+		conn:     conn,
+		interval: interval,
+		messages: make(chan outboundMessage, 256),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the send loop in a new goroutine. Call once per queue.
+func (q *SendQueue) Start() {
+	go q.run()
+}
+
+// Stop ends the send loop. Queued messages not yet sent are dropped.
+func (q *SendQueue) Stop() {
+	close(q.stop)
+}
+
+// Enqueue schedules message to be sent to target, blocking only if the
+// internal buffer is full.
+func (q *SendQueue) Enqueue(target, message string) {
+	q.messages <- outboundMessage{target: target, message: message}
+}
+
+func (q *SendQueue) run() {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			select {
+			case msg := <-q.messages:
+				q.conn.Privmsg(msg.target, msg.message)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultUserPerMinute and defaultChannelPerMinute are the RateLimitConfig
+	// fallbacks when a network's config leaves them unset.
+	defaultUserPerMinute    = 5
+	defaultChannelPerMinute = 20
+
+	// noticeCooldown bounds how often a single user or channel gets a
+	// cooldown notice, so a sustained flood doesn't also flood the notice.
+	noticeCooldown = 30 * time.Second
+)
+
+// RateLimitConfig configures RateLimiter. Zero values fall back to the
+// package defaults.
+type RateLimitConfig struct {
+	UserPerMinute    int
+	ChannelPerMinute int
+}
+
+// RateLimiter caps how often the bot will invoke the LLM: an independent
+// token bucket per (network, channel, nick), plus a shared bucket per
+// (network, channel) so one busy channel can't starve the others.
+type RateLimiter struct {
+	mu             sync.Mutex
+	userBuckets    map[string]*tokenBucket
+	channelBuckets map[string]*tokenBucket
+	lastNotice     map[string]time.Time
+
+	userPerMinute    int
+	channelPerMinute int
+}
+
+// NewRateLimiter builds a RateLimiter from cfg, applying defaults for any
+// zero fields.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	userPerMinute := cfg.UserPerMinute
+	if userPerMinute <= 0 {
+		userPerMinute = defaultUserPerMinute
+	}
+	channelPerMinute := cfg.ChannelPerMinute
+	if channelPerMinute <= 0 {
+		channelPerMinute = defaultChannelPerMinute
+	}
+
+	return &RateLimiter{
+		userBuckets:      make(map[string]*tokenBucket),
+		channelBuckets:   make(map[string]*tokenBucket),
+		lastNotice:       make(map[string]time.Time),
+		userPerMinute:    userPerMinute,
+		channelPerMinute: channelPerMinute,
+	}
+}
+
+// Allow reports whether a request from nick in (network, channel) may
+// proceed. When it returns false, notify reports whether this particular
+// denial is the first in noticeCooldown for the offending bucket, so the
+// caller can send a single cooldown reply instead of one per dropped message.
+func (r *RateLimiter) Allow(network, channel, nick string) (ok, notify bool) {
+	channelKey := network + "/" + channel
+	userKey := channelKey + "/" + nick
+
+	channelBucket := r.bucket(r.channelBuckets, channelKey, r.channelPerMinute)
+	if !channelBucket.TryTake() {
+		return false, r.debounceNotice(channelKey)
+	}
+
+	userBucket := r.bucket(r.userBuckets, userKey, r.userPerMinute)
+	if !userBucket.TryTake() {
+		channelBucket.Refund()
+		return false, r.debounceNotice(userKey)
+	}
+
+	return true, false
+}
+
+func (r *RateLimiter) bucket(set map[string]*tokenBucket, key string, perMinute int) *tokenBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := set[key]
+	if !ok {
+		b = newTokenBucket(perMinute)
+		set[key] = b
+	}
+	return b
+}
+
+func (r *RateLimiter) debounceNotice(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if last, ok := r.lastNotice[key]; ok && time.Since(last) < noticeCooldown {
+		return false
+	}
+	r.lastNotice[key] = time.Now()
+	return true
+}
+
+// tokenBucket is a standard token-bucket limiter: capacity tokens, refilled
+// continuously at refill tokens/second, consumed one at a time.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	refill   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	capacity := float64(perMinute)
+	return &tokenBucket{
+		capacity: capacity,
+		tokens:   capacity,
+		refill:   capacity / 60,
+		last:     time.Now(),
+	}
+}
+
+// TryTake refills the bucket for elapsed time and consumes one token if
+// available.
+func (b *tokenBucket) TryTake() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.refill)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund returns one token to the bucket, e.g. after a companion bucket in
+// the same check rejected the request. Capped at capacity.
+func (b *tokenBucket) Refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.capacity, b.tokens+1)
+}